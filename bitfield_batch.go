@@ -0,0 +1,195 @@
+package rds
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// FieldResult 是BitfieldBatch里单个字段的执行结果
+type FieldResult struct {
+	Value int64
+	Err   error
+}
+
+type bitfieldOp struct {
+	commands []any
+	overflow Overflow
+}
+
+// BitfieldBatch 把多个key的BITFIELD/BITFIELD_RO子命令累积起来，
+// 通过一次Pipeline（或TxPipeline）批量提交，避免逐用户往返Redis。
+// 用法: batch.Get(key, spec).Set(key, specs, vals).Exec()，结果按累积顺序一一对应。
+type BitfieldBatch struct {
+	tx  bool
+	ops []bitfieldOp
+}
+
+// NewBitfieldBatch 创建一个用普通Pipeline提交的批次
+func NewBitfieldBatch() *BitfieldBatch {
+	return &BitfieldBatch{}
+}
+
+// NewBitfieldTxBatch 创建一个用TxPipeline（MULTI/EXEC）提交的批次
+func NewBitfieldTxBatch() *BitfieldBatch {
+	return &BitfieldBatch{tx: true}
+}
+
+// Get 追加一次BITFIELD_RO GET，读取key上按specs切分的多个字段
+func (b *BitfieldBatch) Get(key string, specs ...FieldSpec) *BitfieldBatch {
+	commands := make([]any, 0, len(specs)*3+2)
+	commands = append(commands, "BITFIELD_RO", key)
+	var offset int
+	for _, spec := range specs {
+		commands = append(commands, "GET", spec.typ(), offset)
+		offset += int(spec.Bits) + 1
+	}
+	b.ops = append(b.ops, bitfieldOp{commands: commands, overflow: OverflowSat})
+	return b
+}
+
+// Set 追加一次BITFIELD SET，overflow可选，缺省为SAT
+func (b *BitfieldBatch) Set(key string, specs []FieldSpec, values []int64, overflow ...Overflow) *BitfieldBatch {
+	if len(values) != len(specs) {
+		panic("参数值数量必须与specs一一对应")
+	}
+	ov := resolveBatchOverflow(overflow)
+	commands := make([]any, 0, len(specs)*6+2)
+	commands = append(commands, "BITFIELD", key)
+	var offset int
+	for i, spec := range specs {
+		commands = append(commands, "OVERFLOW", string(ov), "SET", spec.typ(), offset, values[i])
+		offset += int(spec.Bits) + 1
+	}
+	b.ops = append(b.ops, bitfieldOp{commands: commands, overflow: ov})
+	return b
+}
+
+// IncrBy 追加一次BITFIELD INCRBY，overflow可选，缺省为SAT
+func (b *BitfieldBatch) IncrBy(key string, specs []FieldSpec, values []int64, overflow ...Overflow) *BitfieldBatch {
+	if len(values) != len(specs) {
+		panic("参数值数量必须与specs一一对应")
+	}
+	ov := resolveBatchOverflow(overflow)
+	commands := make([]any, 0, len(specs)*6+2)
+	commands = append(commands, "BITFIELD", key)
+	var offset int
+	for i, spec := range specs {
+		commands = append(commands, "OVERFLOW", string(ov), "INCRBY", spec.typ(), offset, values[i])
+		offset += int(spec.Bits) + 1
+	}
+	b.ops = append(b.ops, bitfieldOp{commands: commands, overflow: ov})
+	return b
+}
+
+func resolveBatchOverflow(overflow []Overflow) Overflow {
+	if len(overflow) > 0 {
+		return overflow[0]
+	}
+	return OverflowSat
+}
+
+// Exec 提交累积的所有子命令，返回按追加顺序一一对应的每次调用的字段结果
+func (b *BitfieldBatch) Exec() ([][]FieldResult, error) {
+	if len(b.ops) == 0 {
+		return nil, nil
+	}
+	var pipe redis.Pipeliner
+	if b.tx {
+		pipe = rdb.TxPipeline()
+	} else {
+		pipe = rdb.Pipeline()
+	}
+	cmds := make([]*redis.Cmd, len(b.ops))
+	for i, op := range b.ops {
+		cmds[i] = pipe.Do(ctx, op.commands...)
+	}
+	// Exec的err只表示批次里至少有一条命令失败（例如某个key类型不对），
+	// 每条命令各自的错误已经挂在对应的cmd上，下面按cmd取结果即可单独归因，
+	// 不能因为其中一个失败就丢弃整个批次的结果
+	pipe.Exec(ctx)
+
+	results := make([][]FieldResult, len(b.ops))
+	for i, cmd := range cmds {
+		slice, err := cmd.Slice()
+		if err != nil {
+			results[i] = []FieldResult{{Err: err}}
+			continue
+		}
+		fields := make([]FieldResult, 0, len(slice))
+		for _, v := range slice {
+			n, perr := parseBitfieldReply(v, b.ops[i].overflow)
+			fields = append(fields, FieldResult{Value: n, Err: perr})
+		}
+		results[i] = fields
+	}
+	return results, nil
+}
+
+// ForEachUser 按Redis Cluster的哈希槽把userIDs分组，同一批次内的用户落在同一个槽，
+// 保证批次可以安全地用TxPipeline提交而不会遇到CROSSSLOT。
+// keyForUser必须返回该用户在fn里会用到的、带{tag}哈希标签的代表性key（例如"prefix:signin:{123}:202507"），
+// 分组按这个标签的哈希槽来算，而不是按userID本身——只有fn内所有key都共用同一个{tag}，
+// 分组内的命令才真正落在同一个槽，调用方需要保证这一点。
+// fn在对应分组的BitfieldBatch上追加该用户的操作，分组之间各自独立提交。
+// 注意：返回结果按分组提交顺序聚合，组内保持fn调用顺序，但组间顺序不保证与userIDs一致。
+func ForEachUser(userIDs []uint64, keyForUser func(userID uint64) string, fn func(batch *BitfieldBatch, userID uint64)) ([][]FieldResult, error) {
+	groups := make(map[uint16][]uint64)
+	for _, id := range userIDs {
+		key := keyForUser(id)
+		tag := hashTag(key)
+		if tag == "" {
+			return nil, fmt.Errorf("ForEachUser: key %q 不含{tag}哈希标签，无法保证分组内命令落在同一个槽", key)
+		}
+		slot := hashSlot(tag)
+		groups[slot] = append(groups[slot], id)
+	}
+
+	var all [][]FieldResult
+	for _, ids := range groups {
+		batch := NewBitfieldBatch()
+		for _, id := range ids {
+			fn(batch, id)
+		}
+		results, err := batch.Exec()
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, results...)
+	}
+	return all, nil
+}
+
+// hashTag 按Redis的哈希标签规则从key里提取{}内的子串，不含合法标签时返回空字符串
+func hashTag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return ""
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return ""
+	}
+	return key[start+1 : start+1+end]
+}
+
+// hashSlot 计算key（或哈希标签）在Redis Cluster中的哈希槽（0~16383），算法与Redis保持一致：CRC16/XMODEM对16384取模
+func hashSlot(key string) uint16 {
+	return crc16(key) % 16384
+}
+
+func crc16(buf string) uint16 {
+	var crc uint16
+	for i := 0; i < len(buf); i++ {
+		crc ^= uint16(buf[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
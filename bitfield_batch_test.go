@@ -0,0 +1,46 @@
+package rds
+
+import "testing"
+
+// crc16("123456789")应为0x31C3，是CRC16/XMODEM的标准校验向量，Redis Cluster的槽算法也是基于它
+func TestCRC16KnownVector(t *testing.T) {
+	if got := crc16("123456789"); got != 0x31C3 {
+		t.Fatalf("crc16(\"123456789\")=0x%04X, want 0x31C3", got)
+	}
+}
+
+func TestHashSlotRange(t *testing.T) {
+	for _, key := range []string{"", "foo", "{user1000}.following", "1"} {
+		slot := hashSlot(key)
+		if slot >= 16384 {
+			t.Fatalf("hashSlot(%q)=%d 超出0~16383范围", key, slot)
+		}
+	}
+}
+
+func TestHashTag(t *testing.T) {
+	cases := []struct {
+		key  string
+		want string
+	}{
+		{"prefix:signin:{123}:202507", "123"},
+		{"{}", ""},           // 空标签不算合法标签，与Redis行为一致
+		{"no-brace-here", ""},
+		{"{only-open", ""},
+		{"a{b}c{d}e", "b"}, // 只取第一对花括号
+	}
+	for _, c := range cases {
+		if got := hashTag(c.key); got != c.want {
+			t.Errorf("hashTag(%q)=%q, want %q", c.key, got, c.want)
+		}
+	}
+}
+
+func TestForEachUserRejectsKeyWithoutHashTag(t *testing.T) {
+	_, err := ForEachUser([]uint64{1, 2}, func(id uint64) string {
+		return "prefix:signin:no-tag"
+	}, func(batch *BitfieldBatch, userID uint64) {})
+	if err == nil {
+		t.Fatalf("没有{tag}哈希标签时应该返回错误")
+	}
+}
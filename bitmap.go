@@ -1,6 +1,7 @@
 package rds
 
 import (
+	"errors"
 	"strconv"
 )
 
@@ -58,123 +59,212 @@ func (b *bitmap) BitOp(op string, srcKeys ...any) {
 	rdb.Do(ctx, commands...)
 }
 
+// Overflow 对应BITFIELD的OVERFLOW子命令，决定超出类型范围时的行为
+type Overflow string
+
+const (
+	OverflowWrap Overflow = "WRAP" // 回绕，例如滚动序号
+	OverflowSat  Overflow = "SAT"  // 饱和到最大/最小值，默认行为
+	OverflowFail Overflow = "FAIL" // 失败时该子操作返回nil
+)
+
+// ErrBitfieldOverflow 在OverflowFail模式下，对应子操作因超出范围被Redis拒绝时返回
+var ErrBitfieldOverflow = errors.New("rds: bitfield overflow")
+
+// parseBitfieldReply 解析BITFIELD单个子操作的回复，FAIL模式下nil代表溢出
+func parseBitfieldReply(v any, overflow Overflow) (int64, error) {
+	if v == nil {
+		if overflow == OverflowFail {
+			return 0, ErrBitfieldOverflow
+		}
+		return 0, nil
+	}
+	return v.(int64), nil
+}
+
 type bitfield struct {
 	base
+	overflow Overflow // 默认SAT，可用WithOverflow按实例设置，也可在Set/IncrBy按次覆盖
 }
 
 // bitfield是对bitmap的分段切割，如果用不好，使用NewAutoBitField
 // 用一个bitmap表示多个作用，bitmap如果是对多用户区分二元，bitfield更像对单用户记录多个数字类型字段。
+// typ支持有符号(iN)和无符号(uN)，例如"i32"、"u16"。
 // https://redis.io/docs/latest/commands/bitfield/
 func NewBitField(key string) bitfield {
 	return bitfield{
-		base: newBase(key),
+		base:     newBase(key),
+		overflow: OverflowSat,
 	}
 }
 
-func (b *bitfield) Set(typ string, offset uint32, value uint32) (uint32, error) {
-	slice, err := rdb.Do(ctx, "BITFIELD", b.key, "OVERFLOW", "SAT", "SET", typ, offset, value).Slice()
+// WithOverflow 设置该实例后续操作默认使用的溢出模式
+func (b *bitfield) WithOverflow(overflow Overflow) *bitfield {
+	b.overflow = overflow
+	return b
+}
+
+func (b *bitfield) resolveOverflow(overflow []Overflow) Overflow {
+	if len(overflow) > 0 {
+		return overflow[0]
+	}
+	if b.overflow == "" {
+		return OverflowSat
+	}
+	return b.overflow
+}
+
+// overflow可选传入以覆盖实例默认值
+func (b *bitfield) Set(typ string, offset uint32, value int64, overflow ...Overflow) (int64, error) {
+	ov := b.resolveOverflow(overflow)
+	slice, err := rdb.Do(ctx, "BITFIELD", b.key, "OVERFLOW", string(ov), "SET", typ, offset, value).Slice()
 	if err != nil {
 		return 0, err
 	}
-	return uint32(slice[0].(int64)), nil
+	return parseBitfieldReply(slice[0], ov)
 }
 
-func (b *bitfield) IncrBy(typ string, offset uint32, value uint32) (uint32, error) {
-	slice, err := rdb.Do(ctx, "BITFIELD", b.key, "OVERFLOW", "SAT", "INCRBY", typ, offset, value).Slice()
+// overflow可选传入以覆盖实例默认值
+func (b *bitfield) IncrBy(typ string, offset uint32, value int64, overflow ...Overflow) (int64, error) {
+	ov := b.resolveOverflow(overflow)
+	slice, err := rdb.Do(ctx, "BITFIELD", b.key, "OVERFLOW", string(ov), "INCRBY", typ, offset, value).Slice()
 	if err != nil {
 		return 0, err
 	}
-	return uint32(slice[0].(int64)), nil
+	return parseBitfieldReply(slice[0], ov)
 }
 
-func (b *bitfield) Get(typ string, offset uint32) (uint32, error) {
+func (b *bitfield) Get(typ string, offset uint32) (int64, error) {
 	slice, err := rdb.Do(ctx, "BITFIELD_RO", b.key, "GET", typ, offset).Slice()
 	if err != nil {
 		return 0, err
 	}
-	return uint32(slice[0].(int64)), nil
+	return slice[0].(int64), nil
+}
+
+// FieldSpec 描述autobitfield里的一个字段：位宽与是否有符号
+// 无符号最大63位(u63)，有符号最大64位(i64)，与Redis BITFIELD类型限制一致
+type FieldSpec struct {
+	Bits   uint8
+	Signed bool
+}
+
+func (f FieldSpec) typ() string {
+	prefix := "u"
+	if f.Signed {
+		prefix = "i"
+	}
+	return prefix + strconv.Itoa(int(f.Bits))
 }
 
 type autobitfield struct {
 	base
-	bits []uint8
+	specs    []FieldSpec
+	overflow Overflow
 }
 
 // 对bitfield的自动切割，也是bit位操作
 // 例如使用 32，32 记录 登录IP、登录时间戳。
 // bit位的大小不必为8的倍数（但是实际内存会对齐，剩余部分可以预留）
-// 在考虑数字最大值的情况下节约，如果设置的值超过范围，会保持在最大值，不会溢出。
-// 自动处理都是无符号类型，如果需要存负数，要么使用bitfield，要么用1位表示正负，代码再判断拼接。
-func NewAutoBitField(key string, bits ...uint8) autobitfield {
-	if len(bits) == 0 {
+// 在考虑数字最大值的情况下节约，如果设置的值超过范围，行为由Overflow决定（默认SAT保持最大值不溢出）。
+// 需要存负数时，把对应字段的Signed设为true即可，不必再手动拼位。
+func NewAutoBitField(key string, specs ...FieldSpec) autobitfield {
+	if len(specs) == 0 {
 		panic("至少需要一个参数")
 	}
-	for _, b := range bits {
-		if b > 32 {
-			panic("限制最大32位")
-		}
-		if b == 0 {
+	for _, s := range specs {
+		if s.Bits == 0 {
 			panic("禁止为0")
 		}
+		if s.Signed && s.Bits > 64 {
+			panic("有符号最大64位")
+		}
+		if !s.Signed && s.Bits > 63 {
+			panic("无符号最大63位")
+		}
 	}
 	return autobitfield{
-		base: newBase(key),
-		bits: bits,
+		base:     newBase(key),
+		specs:    specs,
+		overflow: OverflowSat,
 	}
 }
 
-// 返回原值。不会溢出。
-func (b *autobitfield) AutoSet(values ...uint32) ([]uint32, error) {
-	if len(values) != len(b.bits) {
+// WithOverflow 设置该实例后续操作默认使用的溢出模式
+func (b *autobitfield) WithOverflow(overflow Overflow) *autobitfield {
+	b.overflow = overflow
+	return b
+}
+
+func (b *autobitfield) resolveOverflow(overflow []Overflow) Overflow {
+	if len(overflow) > 0 {
+		return overflow[0]
+	}
+	if b.overflow == "" {
+		return OverflowSat
+	}
+	return b.overflow
+}
+
+// 返回原值。overflow可选传入以覆盖实例默认值。
+func (b *autobitfield) AutoSet(values []int64, overflow ...Overflow) ([]int64, error) {
+	if len(values) != len(b.specs) {
 		panic("参数值数量必须与New时一一对应")
 	}
-	commands := make([]any, 0, len(b.bits)*6+2)
+	ov := b.resolveOverflow(overflow)
+	commands := make([]any, 0, len(b.specs)*6+2)
 	commands = append(commands, "BITFIELD", b.key)
 	var offset int
-	for i, bit := range b.bits {
-		commands = append(commands, "OVERFLOW", "SAT", "SET", "u"+strconv.Itoa(int(bit)), offset, values[i])
-		offset += int(bit) + 1
+	for i, spec := range b.specs {
+		commands = append(commands, "OVERFLOW", string(ov), "SET", spec.typ(), offset, values[i])
+		offset += int(spec.Bits) + 1
 	}
 
-	return b.autodo(commands)
+	return b.autodo(commands, ov)
 }
 
-// 返回增长后的值。不会溢出。
-func (b *autobitfield) AutoIncrBy(values ...uint32) ([]uint32, error) {
-	if len(values) != len(b.bits) {
+// 返回增长后的值。overflow可选传入以覆盖实例默认值。
+func (b *autobitfield) AutoIncrBy(values []int64, overflow ...Overflow) ([]int64, error) {
+	if len(values) != len(b.specs) {
 		panic("参数值数量必须与New时一一对应")
 	}
+	ov := b.resolveOverflow(overflow)
 	commands := make([]any, 0, len(values)*6+2)
 	commands = append(commands, "BITFIELD", b.key)
 	var offset int
-	for i, bit := range b.bits {
-		commands = append(commands, "OVERFLOW", "SAT", "INCRBY", "u"+strconv.Itoa(int(bit)), offset, values[i])
-		offset += int(bit) + 1
+	for i, spec := range b.specs {
+		commands = append(commands, "OVERFLOW", string(ov), "INCRBY", spec.typ(), offset, values[i])
+		offset += int(spec.Bits) + 1
 	}
 
-	return b.autodo(commands)
+	return b.autodo(commands, ov)
 }
 
-func (b *autobitfield) AutoGet() ([]uint32, error) {
-	commands := make([]any, 0, len(b.bits)*3+2)
+func (b *autobitfield) AutoGet() ([]int64, error) {
+	commands := make([]any, 0, len(b.specs)*3+2)
 	commands = append(commands, "BITFIELD_RO", b.key)
 	var offset int
-	for _, bit := range b.bits {
-		commands = append(commands, "GET", "u"+strconv.Itoa(int(bit)), offset)
-		offset += int(bit) + 1
+	for _, spec := range b.specs {
+		commands = append(commands, "GET", spec.typ(), offset)
+		offset += int(spec.Bits) + 1
 	}
 
-	return b.autodo(commands)
+	// GET不会因OVERFLOW溢出，FAIL语义只对SET/INCRBY生效
+	return b.autodo(commands, OverflowSat)
 }
 
-func (b *autobitfield) autodo(commands []any) ([]uint32, error) {
-	slice, err := rdb.Do(ctx, commands...).Int64Slice()
+func (b *autobitfield) autodo(commands []any, overflow Overflow) ([]int64, error) {
+	slice, err := rdb.Do(ctx, commands...).Slice()
 	if err != nil {
 		return nil, err
 	}
-	r := make([]uint32, 0, len(slice))
-	for _, n := range slice {
-		r = append(r, uint32(n))
+	r := make([]int64, 0, len(slice))
+	for _, v := range slice {
+		n, err := parseBitfieldReply(v, overflow)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, n)
 	}
 	return r, nil
 }
@@ -0,0 +1,98 @@
+package rds
+
+import (
+	"sync"
+	"time"
+)
+
+// OnPreallocChunkFunc 每写完一个预分配分块后回调，bytesWritten是本次分块的字节数，可用于监控进度
+type OnPreallocChunkFunc func(bytesWritten int)
+
+// bitmapPrealloc 在bitmap基础上把大offset的一次性512M同步分配摊薄成多次小块SETRANGE，
+// 代价是分配期间key的长度是逐步增长的，期间直接BitCount/BitOp的范围可能还没写到。
+// 如果对分配延迟不敏感，直接用bitmap即可。
+type bitmapPrealloc struct {
+	bitmap
+	maxOffset uint32
+	chunk     uint32 // 每个分块的字节数
+	delay     time.Duration
+	onChunk   OnPreallocChunkFunc
+	once      sync.Once
+	growMu    sync.Mutex // 串行化后台预热与SetBitSafe各自发起的growTo，避免两者交叉写同一个key
+}
+
+// NewBitmapWithPreallocation 创建时立即在后台按chunk字节大小分块预热到maxOffset，
+// 避免第一次SetBit(offset接近maxOffset)时Redis同步分配大块内存导致主线程卡顿
+func NewBitmapWithPreallocation(key string, maxOffset uint32, chunk uint32) *bitmapPrealloc {
+	b := &bitmapPrealloc{
+		bitmap:    NewBitmap(key),
+		maxOffset: maxOffset,
+		chunk:     chunk,
+		delay:     10 * time.Millisecond,
+	}
+	b.once.Do(func() {
+		go b.growTo(int64(b.maxOffset)/8 + 1)
+	})
+	return b
+}
+
+// WithDelay 设置分块之间的间隔，间隔越大对Redis影响越小，但预热完成得越慢
+func (b *bitmapPrealloc) WithDelay(delay time.Duration) *bitmapPrealloc {
+	b.delay = delay
+	return b
+}
+
+// OnPreallocChunk 注册分块写入完成后的观测回调
+func (b *bitmapPrealloc) OnPreallocChunk(fn OnPreallocChunkFunc) *bitmapPrealloc {
+	b.onChunk = fn
+	return b
+}
+
+// SetBitSafe 在当前字符串长度明显小于offset所需长度时，先分块预热再SetBit，
+// 避免单次SETBIT触发一次性大块同步分配
+func (b *bitmapPrealloc) SetBitSafe(offset uint32, ok bool) error {
+	needed := int64(offset)/8 + 1
+	length, _ := rdb.StrLen(ctx, b.key).Result()
+	if needed-length > int64(b.chunk) {
+		b.growTo(needed)
+	}
+	return b.SetBit(offset, ok)
+}
+
+// growTo 把key从当前长度分块预热到target字节，每块写完调用onChunk并按delay休眠。
+// 每次分块写入前都重新查询STRLEN，只从这个真实的当前长度继续往后写零，
+// 绝不回头覆盖已经存在的字节——这样无论是SetBit/SetBitSafe的真实写入，
+// 还是另一个并发的growTo调用（growMu保证两者不会同时发请求），
+// 已经写到key里的数据都不会被后续的分块覆盖掉。
+func (b *bitmapPrealloc) growTo(target int64) {
+	b.growMu.Lock()
+	defer b.growMu.Unlock()
+
+	const growChunkScript = `if redis.call('STRLEN', KEYS[1]) ~= tonumber(ARGV[1]) then return 0 end
+redis.call('SETRANGE', KEYS[1], ARGV[1], ARGV[2])
+return 1`
+
+	chunk := int64(b.chunk)
+	if chunk <= 0 {
+		chunk = target
+	}
+	for {
+		length, _ := rdb.StrLen(ctx, b.key).Result()
+		if length >= target {
+			return
+		}
+		n := chunk
+		if length+n > target {
+			n = target - length
+		}
+		// STRLEN校验与SETRANGE在同一个脚本里原子执行：若长度在上面的查询之后已被SetBit改变，
+		// 脚本不写入，下一轮按新长度重来
+		written, _ := rdb.Eval(ctx, growChunkScript, []string{b.key}, length, make([]byte, n)).Int64()
+		if written > 0 && b.onChunk != nil {
+			b.onChunk(int(n))
+		}
+		if b.delay > 0 {
+			time.Sleep(b.delay)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package rds
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 单个container覆盖offset的低16位（65536个位）
+// 基数不超过arrayMaxCardinality时用有序数组存储，省内存；超过后转成位图存储，保证最坏情况下也只占8KB
+const arrayMaxCardinality = 4096
+const bitmapWords = 1024 // 1024*64bit = 65536位 = 8KB
+
+type container struct {
+	array    []uint16 // 有序去重，稀疏时使用
+	bitmap   []uint64 // len==bitmapWords，密集时使用
+	isBitmap bool
+}
+
+func newArrayContainer() *container {
+	return &container{}
+}
+
+func (c *container) cardinality() int {
+	if c.isBitmap {
+		n := 0
+		for _, w := range c.bitmap {
+			n += popcount(w)
+		}
+		return n
+	}
+	return len(c.array)
+}
+
+func (c *container) contains(v uint16) bool {
+	if c.isBitmap {
+		return c.bitmap[v>>6]&(1<<(v&63)) != 0
+	}
+	i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= v })
+	return i < len(c.array) && c.array[i] == v
+}
+
+func (c *container) add(v uint16) {
+	if c.isBitmap {
+		c.bitmap[v>>6] |= 1 << (v & 63)
+		return
+	}
+	i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= v })
+	if i < len(c.array) && c.array[i] == v {
+		return
+	}
+	c.array = append(c.array, 0)
+	copy(c.array[i+1:], c.array[i:])
+	c.array[i] = v
+	if len(c.array) > arrayMaxCardinality {
+		c.toBitmap()
+	}
+}
+
+func (c *container) toBitmap() {
+	bm := make([]uint64, bitmapWords)
+	for _, v := range c.array {
+		bm[v>>6] |= 1 << (v & 63)
+	}
+	c.bitmap = bm
+	c.array = nil
+	c.isBitmap = true
+}
+
+// values 按升序返回容器内所有元素
+func (c *container) values() []uint16 {
+	if !c.isBitmap {
+		return c.array
+	}
+	r := make([]uint16, 0, c.cardinality())
+	for i, w := range c.bitmap {
+		for w != 0 {
+			b := trailingZeros64(w)
+			r = append(r, uint16(i*64+b))
+			w &= w - 1
+		}
+	}
+	return r
+}
+
+func popcount(w uint64) int {
+	n := 0
+	for w != 0 {
+		w &= w - 1
+		n++
+	}
+	return n
+}
+
+func trailingZeros64(w uint64) int {
+	n := 0
+	for w&1 == 0 {
+		w >>= 1
+		n++
+	}
+	return n
+}
+
+// roaringBitmap 是bitmap的客户端压缩缓存，用高16位分桶+容器(数组/位图)存储，
+// 用于把BITOP这类集合运算下沉到本地，避免大offset下位图占用512M内存以及往返Redis的开销。
+// 使用方式: Load拉取原始字符串解码，AndOrXor等在本地运算，Store按bitmap原始字节布局写回。
+type roaringBitmap struct {
+	base
+	containers map[uint16]*container
+}
+
+// NewRoaringBitmap 创建一个绑定key的roaringBitmap，需要先Load才有数据
+func NewRoaringBitmap(key string) roaringBitmap {
+	return roaringBitmap{
+		base:       newBase(key),
+		containers: make(map[uint16]*container),
+	}
+}
+
+// Load 通过GET拉取key对应的原始bitmap字符串并解码为roaring结构
+func (r *roaringBitmap) Load() error {
+	raw, err := rdb.Get(ctx, r.key).Bytes()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	r.containers = decodeBitmapBytes(raw)
+	return nil
+}
+
+// decodeBitmapBytes按Redis的bitmap字节布局（MSB优先，8位对齐）把原始字节解码成containers，
+// 是toBytes的逆操作，单独拆出来方便脱离rdb直接测试编解码是否互为逆运算
+func decodeBitmapBytes(raw []byte) map[uint16]*container {
+	containers := make(map[uint16]*container)
+	addTo := func(offset uint32) {
+		hi := uint16(offset >> 16)
+		lo := uint16(offset)
+		c := containers[hi]
+		if c == nil {
+			c = newArrayContainer()
+			containers[hi] = c
+		}
+		c.add(lo)
+	}
+	for byteIdx, b := range raw {
+		if b == 0 {
+			continue
+		}
+		for bit := 0; bit < 8; bit++ {
+			if b&(1<<(7-bit)) == 0 {
+				continue
+			}
+			addTo(uint32(byteIdx)*8 + uint32(bit))
+		}
+	}
+	return containers
+}
+
+// Store 按Redis的bitmap字节布局（MSB优先，8位对齐）写回，写回后GetBit/BitCount结果一致。
+// r必须绑定了非空key（即通过NewRoaringBitmap创建，而非And/Or/Xor/AndNot的结果），否则panic。
+func (r *roaringBitmap) Store() error {
+	if r.key == "" {
+		panic("roaringBitmap: 不能Store一个未绑定key的结果，请先用Rekey指定目标key")
+	}
+	raw := r.toBytes()
+	return rdb.Set(ctx, r.key, raw, 0).Err()
+}
+
+// Rekey 给And/Or/Xor/AndNot等组合运算产生的结果绑定一个目标key，之后才能调用Store落地
+func (r *roaringBitmap) Rekey(key string) *roaringBitmap {
+	r.base = newBase(key)
+	return r
+}
+
+func (r *roaringBitmap) toBytes() []byte {
+	var maxOffset uint32
+	has := false
+	for hi, c := range r.containers {
+		for _, lo := range c.values() {
+			off := uint32(hi)<<16 | uint32(lo)
+			if !has || off > maxOffset {
+				maxOffset = off
+				has = true
+			}
+		}
+	}
+	if !has {
+		return nil
+	}
+	raw := make([]byte, maxOffset/8+1)
+	r.Iterate(func(offset uint32) bool {
+		raw[offset/8] |= 1 << (7 - offset%8)
+		return true
+	})
+	return raw
+}
+
+func (r *roaringBitmap) addOffset(offset uint32) {
+	hi := uint16(offset >> 16)
+	lo := uint16(offset)
+	c := r.containers[hi]
+	if c == nil {
+		c = newArrayContainer()
+		r.containers[hi] = c
+	}
+	c.add(lo)
+}
+
+// Cardinality 返回当前基数（1的个数）
+func (r *roaringBitmap) Cardinality() uint64 {
+	var n uint64
+	for _, c := range r.containers {
+		n += uint64(c.cardinality())
+	}
+	return n
+}
+
+// Iterate 按升序遍历所有offset，fn返回false时提前终止
+func (r *roaringBitmap) Iterate(fn func(offset uint32) bool) {
+	his := make([]uint16, 0, len(r.containers))
+	for hi := range r.containers {
+		his = append(his, hi)
+	}
+	sort.Slice(his, func(i, j int) bool { return his[i] < his[j] })
+	for _, hi := range his {
+		for _, lo := range r.containers[hi].values() {
+			if !fn(uint32(hi)<<16 | uint32(lo)) {
+				return
+			}
+		}
+	}
+}
+
+// Rank 返回小于等于offset的元素个数
+func (r *roaringBitmap) Rank(offset uint32) uint64 {
+	var n uint64
+	r.Iterate(func(o uint32) bool {
+		if o > offset {
+			return false
+		}
+		n++
+		return true
+	})
+	return n
+}
+
+// Select 返回第rank个元素（rank从0开始），不存在时ok为false
+func (r *roaringBitmap) Select(rank uint64) (offset uint32, ok bool) {
+	var i uint64
+	r.Iterate(func(o uint32) bool {
+		if i == rank {
+			offset, ok = o, true
+			return false
+		}
+		i++
+		return true
+	})
+	return
+}
+
+// And 与另一个roaringBitmap求交集，返回不绑定key的新roaringBitmap（本地运算，不落地）。
+// 结果需要Rekey后才能Store，否则Store会panic。
+func (r *roaringBitmap) And(other *roaringBitmap) *roaringBitmap {
+	return r.combine(other, func(a, b bool) bool { return a && b })
+}
+
+// Or 与另一个roaringBitmap求并集
+func (r *roaringBitmap) Or(other *roaringBitmap) *roaringBitmap {
+	return r.combine(other, func(a, b bool) bool { return a || b })
+}
+
+// Xor 与另一个roaringBitmap求对称差集
+func (r *roaringBitmap) Xor(other *roaringBitmap) *roaringBitmap {
+	return r.combine(other, func(a, b bool) bool { return a != b })
+}
+
+// AndNot 返回属于r但不属于other的元素集合
+func (r *roaringBitmap) AndNot(other *roaringBitmap) *roaringBitmap {
+	return r.combine(other, func(a, b bool) bool { return a && !b })
+}
+
+func (r *roaringBitmap) combine(other *roaringBitmap, keep func(a, b bool) bool) *roaringBitmap {
+	res := &roaringBitmap{containers: make(map[uint16]*container)}
+	seen := make(map[uint32]bool)
+	visit := func(offset uint32, inR, inOther bool) {
+		if seen[offset] {
+			return
+		}
+		seen[offset] = true
+		if keep(inR, inOther) {
+			res.addOffset(offset)
+		}
+	}
+	r.Iterate(func(o uint32) bool {
+		visit(o, true, other.contains(o))
+		return true
+	})
+	other.Iterate(func(o uint32) bool {
+		visit(o, r.contains(o), true)
+		return true
+	})
+	return res
+}
+
+func (r *roaringBitmap) contains(offset uint32) bool {
+	c := r.containers[uint16(offset>>16)]
+	return c != nil && c.contains(uint16(offset))
+}
@@ -0,0 +1,117 @@
+package rds
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func newTestRoaringBitmap(offsets ...uint32) *roaringBitmap {
+	r := &roaringBitmap{containers: make(map[uint16]*container)}
+	for _, o := range offsets {
+		r.addOffset(o)
+	}
+	return r
+}
+
+func offsetsOf(r *roaringBitmap) []uint32 {
+	var got []uint32
+	r.Iterate(func(offset uint32) bool {
+		got = append(got, offset)
+		return true
+	})
+	return got
+}
+
+func TestContainerArrayBitmapConversion(t *testing.T) {
+	c := newArrayContainer()
+	for i := 0; i < arrayMaxCardinality; i++ {
+		c.add(uint16(i * 2))
+	}
+	if c.isBitmap {
+		t.Fatalf("未超过arrayMaxCardinality前应仍是数组容器")
+	}
+	if c.cardinality() != arrayMaxCardinality {
+		t.Fatalf("cardinality()=%d, want %d", c.cardinality(), arrayMaxCardinality)
+	}
+
+	c.add(uint16(arrayMaxCardinality * 2)) // 触发转换
+	if !c.isBitmap {
+		t.Fatalf("超过arrayMaxCardinality后应转为位图容器")
+	}
+	if c.cardinality() != arrayMaxCardinality+1 {
+		t.Fatalf("转换后cardinality()=%d, want %d", c.cardinality(), arrayMaxCardinality+1)
+	}
+	for i := 0; i < arrayMaxCardinality; i++ {
+		if !c.contains(uint16(i * 2)) {
+			t.Fatalf("转换后应保留原有元素 %d", i*2)
+		}
+	}
+}
+
+func TestRoaringBitmapEncodeDecodeRoundTrip(t *testing.T) {
+	offsets := []uint32{0, 1, 7, 8, 65535, 65536, 70000, 1<<20 + 3}
+	r := newTestRoaringBitmap(offsets...)
+
+	raw := r.toBytes()
+	containers := decodeBitmapBytes(raw)
+	decoded := &roaringBitmap{containers: containers}
+
+	got := offsetsOf(decoded)
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	want := append([]uint32(nil), offsets...)
+	sort.Slice(want, func(i, j int) bool { return want[i] < want[j] })
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("编解码往返后offset集合不一致: got=%v, want=%v", got, want)
+	}
+}
+
+func TestRoaringBitmapSetAlgebra(t *testing.T) {
+	a := newTestRoaringBitmap(1, 2, 3, 70000)
+	b := newTestRoaringBitmap(2, 3, 4, 70000)
+
+	cases := []struct {
+		name string
+		got  []uint32
+		want []uint32
+	}{
+		{"And", offsetsOf(a.And(b)), []uint32{2, 3, 70000}},
+		{"Or", offsetsOf(a.Or(b)), []uint32{1, 2, 3, 4, 70000}},
+		{"Xor", offsetsOf(a.Xor(b)), []uint32{1, 4}},
+		{"AndNot", offsetsOf(a.AndNot(b)), []uint32{1}},
+	}
+	for _, c := range cases {
+		sort.Slice(c.got, func(i, j int) bool { return c.got[i] < c.got[j] })
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Errorf("%s: got=%v, want=%v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestRoaringBitmapRankSelect(t *testing.T) {
+	r := newTestRoaringBitmap(5, 10, 20)
+
+	if rank := r.Rank(10); rank != 2 {
+		t.Errorf("Rank(10)=%d, want 2", rank)
+	}
+	if rank := r.Rank(4); rank != 0 {
+		t.Errorf("Rank(4)=%d, want 0", rank)
+	}
+
+	if off, ok := r.Select(1); !ok || off != 10 {
+		t.Errorf("Select(1)=(%d,%v), want (10,true)", off, ok)
+	}
+	if _, ok := r.Select(3); ok {
+		t.Errorf("Select(3) 应该不存在")
+	}
+}
+
+func TestRoaringBitmapStorePanicsWithoutKey(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("对未绑定key的组合结果调用Store应该panic")
+		}
+	}()
+	r := newTestRoaringBitmap(1, 2, 3)
+	_ = r.Store()
+}
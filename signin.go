@@ -0,0 +1,142 @@
+package rds
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrGranularityNotDaily 表示调用了只对Daily粒度有意义的方法，但SignIn是按Weekly/Monthly创建的
+var ErrGranularityNotDaily = errors.New("rds: 该方法仅支持Daily粒度的SignIn")
+
+// Granularity 决定SignIn打卡的最小时间单位
+type Granularity int
+
+const (
+	Daily Granularity = iota
+	Weekly
+	Monthly
+)
+
+// SignIn 基于bitmap封装“每日打卡/月历/连续N天留存”这类常见场景。
+// 每个用户一个月历bitmap（key: prefix:signin:{userID}:{yyyymm}；Weekly/Monthly粒度按年分桶，为prefix:signin:{userID}:{yyyy}），
+// 每天一个活跃用户cohort bitmap（key: prefix:cohort:{yyyymmdd}，offset为userID），
+// 两者结合可以用BITOP AND+BITCOUNT算任意两天的留存人数。
+type SignIn struct {
+	prefix      string
+	granularity Granularity
+	cohortTTL   time.Duration // cohort key的过期时间，<=0表示不过期
+}
+
+// NewSignIn 创建一个SignIn，prefix用于隔离不同业务的key空间
+func NewSignIn(prefix string, granularity Granularity) SignIn {
+	return SignIn{prefix: prefix, granularity: granularity}
+}
+
+// WithCohortTTL 设置cohort:{yyyymmdd}这类临时统计key的过期时间
+func (s *SignIn) WithCohortTTL(ttl time.Duration) *SignIn {
+	s.cohortTTL = ttl
+	return s
+}
+
+// calendarKey按granularity决定key覆盖的时间跨度，使它里面的bit位置与offset()算出来的一致：
+// Daily按月分桶（一个月最多31天），Weekly按ISO周所属的年份分桶（一年最多53周），Monthly按年分桶（12个月）
+func (s *SignIn) calendarKey(userID uint64, t time.Time) string {
+	switch s.granularity {
+	case Weekly:
+		year, _ := t.ISOWeek()
+		return fmt.Sprintf("%s:signin:%d:%04d", s.prefix, userID, year)
+	case Monthly:
+		return fmt.Sprintf("%s:signin:%d:%s", s.prefix, userID, t.Format("2006"))
+	default:
+		return fmt.Sprintf("%s:signin:%d:%s", s.prefix, userID, t.Format("200601"))
+	}
+}
+
+func (s *SignIn) cohortKey(t time.Time) string {
+	return fmt.Sprintf("%s:cohort:%s", s.prefix, t.Format("20060102"))
+}
+
+// offset 按granularity把t折算成月历bitmap内的bit位置
+func (s *SignIn) offset(t time.Time) uint32 {
+	switch s.granularity {
+	case Weekly:
+		_, week := t.ISOWeek()
+		return uint32(week - 1)
+	case Monthly:
+		return uint32(t.Month() - 1)
+	default:
+		return uint32(t.Day() - 1)
+	}
+}
+
+// Mark 记录userID在t这一天的打卡，同时写入当天的活跃用户cohort集合
+func (s *SignIn) Mark(userID uint64, t time.Time) error {
+	calendar := NewBitmap(s.calendarKey(userID, t))
+	if err := calendar.SetBit(s.offset(t), true); err != nil {
+		return err
+	}
+
+	cohortKey := s.cohortKey(t)
+	cohort := NewBitmap(cohortKey)
+	if err := cohort.SetBit(uint32(userID), true); err != nil {
+		return err
+	}
+	if s.cohortTTL > 0 {
+		rdb.Expire(ctx, cohortKey, s.cohortTTL)
+	}
+	return nil
+}
+
+// IsMarked 判断userID在t这一天是否已打卡
+func (s *SignIn) IsMarked(userID uint64, t time.Time) bool {
+	calendar := NewBitmap(s.calendarKey(userID, t))
+	return calendar.GetBit(s.offset(t))
+}
+
+// MonthCalendar 一次BITFIELD_RO GET u32把整月读成一个整数，再本地按位展开，
+// 避免逐天GetBit产生31次往返。仅支持Daily粒度——Weekly/Monthly的月历key是按年分桶的，
+// 这里假定的yyyymm按月分桶对它们不成立，调用时返回ErrGranularityNotDaily。
+func (s *SignIn) MonthCalendar(userID uint64, year int, month time.Month) ([31]bool, error) {
+	var days [31]bool
+	if s.granularity != Daily {
+		return days, ErrGranularityNotDaily
+	}
+	key := fmt.Sprintf("%s:signin:%d:%04d%02d", s.prefix, userID, year, int(month))
+	bf := NewBitField(key)
+	v, err := bf.Get("u32", 0)
+	if err != nil {
+		return days, err
+	}
+	for i := 0; i < 31; i++ {
+		days[i] = v&(1<<uint(31-i)) != 0
+	}
+	return days, nil
+}
+
+// ContinuousDays 从asOf往前数连续打卡天数，遇到未打卡的一天就停止。仅支持Daily粒度——
+// Weekly/Monthly下同一个周期内每天都会命中同一个bit，逐天回溯算出来的不是“连续周期数”，
+// 而是被星期/月份边界污染的天数，调用时返回ErrGranularityNotDaily。
+func (s *SignIn) ContinuousDays(userID uint64, asOf time.Time) (int64, error) {
+	if s.granularity != Daily {
+		return 0, ErrGranularityNotDaily
+	}
+	var days int64
+	t := asOf
+	for s.IsMarked(userID, t) {
+		days++
+		t = t.AddDate(0, 0, -1)
+	}
+	return days, nil
+}
+
+// RetentionCohort 用BITOP AND求day1和dayN两天活跃用户cohort的交集，返回BITCOUNT即留存人数。
+// tmpKey只是算BITCOUNT用的临时结果，读完即删，不受cohortTTL影响（cohortTTL是给
+// cohort:{yyyymmdd}这种活跃用户集合本身用的，两者生命周期不一样）。
+func (s *SignIn) RetentionCohort(day1, dayN time.Time) int64 {
+	tmpKey := fmt.Sprintf("%s:cohort:retention:%s:%s", s.prefix, day1.Format("20060102"), dayN.Format("20060102"))
+	tmp := NewBitmap(tmpKey)
+	tmp.BitOp("AND", s.cohortKey(day1), s.cohortKey(dayN))
+	defer rdb.Del(ctx, tmpKey)
+	return tmp.BitCount(0, -1)
+}
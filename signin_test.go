@@ -0,0 +1,65 @@
+package rds
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignInOffsetByGranularity(t *testing.T) {
+	// 2025-07-25是周五，ISO周号为第30周
+	d := time.Date(2025, 7, 25, 0, 0, 0, 0, time.UTC)
+
+	daily := SignIn{granularity: Daily}
+	if off := daily.offset(d); off != 24 {
+		t.Errorf("Daily offset=%d, want 24", off)
+	}
+
+	weekly := SignIn{granularity: Weekly}
+	if off := weekly.offset(d); off != 29 {
+		t.Errorf("Weekly offset=%d, want 29", off)
+	}
+
+	monthly := SignIn{granularity: Monthly}
+	if off := monthly.offset(d); off != 6 {
+		t.Errorf("Monthly offset=%d, want 6", off)
+	}
+}
+
+func TestSignInCalendarKeyBucketsMatchOffsetGranularity(t *testing.T) {
+	d := time.Date(2025, 7, 25, 0, 0, 0, 0, time.UTC)
+
+	daily := SignIn{prefix: "p", granularity: Daily}
+	if key := daily.calendarKey(1, d); key != "p:signin:1:202507" {
+		t.Errorf("Daily calendarKey=%q, want p:signin:1:202507", key)
+	}
+
+	weekly := SignIn{prefix: "p", granularity: Weekly}
+	if key := weekly.calendarKey(1, d); key != "p:signin:1:2025" {
+		t.Errorf("Weekly calendarKey=%q, want p:signin:1:2025", key)
+	}
+
+	monthly := SignIn{prefix: "p", granularity: Monthly}
+	if key := monthly.calendarKey(1, d); key != "p:signin:1:2025" {
+		t.Errorf("Monthly calendarKey=%q, want p:signin:1:2025", key)
+	}
+
+	// Weekly/Monthly按年分桶后，同一年内任意两天的key必须相同，否则offset会在不同的key间对不齐
+	other := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	if weekly.calendarKey(1, d) != weekly.calendarKey(1, other) {
+		t.Errorf("Weekly粒度下同一年内的calendarKey应该相同")
+	}
+}
+
+func TestSignInMonthCalendarRejectsNonDaily(t *testing.T) {
+	weekly := SignIn{prefix: "p", granularity: Weekly}
+	if _, err := weekly.MonthCalendar(1, 2025, time.July); err != ErrGranularityNotDaily {
+		t.Errorf("MonthCalendar在Weekly粒度下应返回ErrGranularityNotDaily, got %v", err)
+	}
+}
+
+func TestSignInContinuousDaysRejectsNonDaily(t *testing.T) {
+	monthly := SignIn{prefix: "p", granularity: Monthly}
+	if _, err := monthly.ContinuousDays(1, time.Now()); err != ErrGranularityNotDaily {
+		t.Errorf("ContinuousDays在Monthly粒度下应返回ErrGranularityNotDaily, got %v", err)
+	}
+}